@@ -8,12 +8,15 @@ import (
 	"net"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/harmonica"
+	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
 	"github.com/charmbracelet/ssh"
 	"github.com/charmbracelet/wish"
@@ -21,6 +24,8 @@ import (
 	"github.com/charmbracelet/wish/bubbletea"
 	"github.com/charmbracelet/wish/logging"
 
+	"github.com/JoeyShapiro/GoGo/src/rules"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -29,10 +34,10 @@ const (
 	port = "23234"
 )
 
-// TODO add more games some way, then interact with them
-
 var (
-	games map[string]*Game
+	games   map[string]*Game
+	gamesMu sync.RWMutex // guards games: every session's menu and game loop run on their own goroutine
+	db      *sql.DB
 )
 
 //go:embed gogo.sql
@@ -40,7 +45,8 @@ var gogodotsql string
 
 func main() {
 	// Open database
-	db, err := sql.Open("sqlite3", "./gogo.db")
+	var err error
+	db, err = sql.Open("sqlite3", "./gogo.db")
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -52,8 +58,12 @@ func main() {
 	games = make(map[string]*Game)
 
 	uuid := uuid.New().String()
-	game := NewGame(uuid, db)
+	game := NewGame(uuid, db, BOARD_SIZE)
+	gamesMu.Lock()
 	games[uuid] = &game
+	gamesMu.Unlock()
+	go broadcastLoop(&game)
+	go clockLoop(&game, db)
 
 	s, err := wish.NewServer(
 		wish.WithAddress(net.JoinHostPort(host, port)),
@@ -68,22 +78,6 @@ func main() {
 		log.Error("Could not start server", "error", err)
 	}
 
-	go func() {
-		for {
-			msg := <-game.Conn
-			switch msg := msg.(type) {
-			case SendMsg:
-				for i := range game.Players {
-					if i != msg.Id {
-						*game.PlayerConns[i] <- SendMsg{Id: i}
-					}
-				}
-			default:
-				log.Warn("Unknown message type", "msg", msg)
-			}
-		}
-	}()
-
 	done := make(chan os.Signal, 1)
 	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	log.Info("Starting SSH server", "host", host, "port", port)
@@ -108,6 +102,70 @@ func initdb(db *sql.DB) error {
 	return err
 }
 
+// broadcastLoop relays a game's internal Conn messages out to every
+// connected player. SendMsg, JoinMsg and LeaveMsg skip whichever player
+// caused them; EndMsg and ClockMsg go to every player. It is
+// started once per Game, both for the game created at boot and for every
+// game a player creates from the menu.
+func broadcastLoop(game *Game) {
+	for msg := range game.Conn {
+		game.mu.Lock()
+		switch msg := msg.(type) {
+		case SendMsg:
+			for i := range game.Players {
+				if i != msg.Id {
+					sendPlayer(game, i, SendMsg{Id: i})
+				}
+			}
+		case JoinMsg:
+			for i := range game.Players {
+				if i != msg.Id {
+					sendPlayer(game, i, msg)
+				}
+			}
+		case LeaveMsg:
+			// The leaving player's own connection is never read from again
+			// (its program has already quit), so stop targeting it instead
+			// of letting its buffer fill and wedge every future broadcast.
+			if msg.Id >= 0 && msg.Id < len(game.PlayerConns) {
+				game.PlayerConns[msg.Id] = nil
+			}
+			for i := range game.Players {
+				if i != msg.Id {
+					sendPlayer(game, i, msg)
+				}
+			}
+		case EndMsg:
+			for i := range game.Players {
+				sendPlayer(game, i, msg)
+			}
+		case ClockMsg:
+			for i := range game.Players {
+				sendPlayer(game, i, msg)
+			}
+		default:
+			log.Warn("Unknown message type", "msg", msg)
+		}
+		game.mu.Unlock()
+	}
+}
+
+// sendPlayer delivers msg to player i's connection without blocking. A nil
+// slot (a seat nobody has claimed, or one cleared on LeaveMsg) and a full
+// buffer (an SSH session that dropped without sending LeaveMsg) are both
+// skipped rather than stalling broadcastLoop, which is shared by every
+// player and spectator in the game.
+func sendPlayer(game *Game, i int, msg tea.Msg) {
+	conn := game.PlayerConns[i]
+	if conn == nil {
+		return
+	}
+	select {
+	case *conn <- msg:
+	default:
+	}
+}
+
 func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 	// This should never fail, as we are using the activeterm middleware.
 	pty, _, _ := s.Pty()
@@ -115,13 +173,23 @@ func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 	renderer := bubbletea.MakeRenderer(s)
 	txtStyle := renderer.NewStyle()
 
-	gameId := uuid.New().String()
-	game, exists := games[gameId]
-	if !exists {
-		log.Error("Game not found", "game_id", gameId)
-		return nil, []tea.ProgramOption{tea.WithAltScreen()}
+	m := ModelMenu{
+		txtStyle: txtStyle,
+		term:     pty.Term,
+		width:    pty.Window.Width,
+		height:   pty.Window.Height,
+		sizes:    []int{9, 13, 19},
 	}
 
+	return m, []tea.ProgramOption{tea.WithAltScreen()}
+}
+
+// joinGame registers a new connection on an existing game and returns the
+// ModelGame the session should hand off to. piece is Spectator when the
+// game already has two players.
+func joinGame(gameId string, game *Game, txtStyle lipgloss.Style, term string, width, height int) ModelGame {
+	game.mu.Lock()
+
 	var piece Cell
 	switch game.Players {
 	case 0:
@@ -129,117 +197,385 @@ func teaHandler(s ssh.Session) (tea.Model, []tea.ProgramOption) {
 	case 1:
 		piece = Black
 	default:
-		log.Error("Too many players connected", "players", game.Players)
-		return nil, []tea.ProgramOption{tea.WithAltScreen()}
+		piece = Spectator
 	}
 
 	m := ModelGame{
-		txtStyle: txtStyle,
-		term:     pty.Term,
-		width:    pty.Window.Width,
-		height:   pty.Window.Height,
-		Player:   piece,
-		Conn:     make(chan tea.Msg, 1),
-		Id:       game.Players,
-		GameId:   gameId,
+		txtStyle:     txtStyle,
+		term:         term,
+		width:        width,
+		height:       height,
+		Player:       piece,
+		Conn:         make(chan tea.Msg, 1),
+		Id:           game.Players,
+		GameId:       gameId,
+		lastSeen:     -1,
+		cursorX:      -1,
+		cursorY:      -1,
+		cursorSpring: harmonica.NewSpring(harmonica.FPS(animFPS), cursorFrequency, cursorDamping),
+		stoneSpring:  harmonica.NewSpring(harmonica.FPS(animFPS), popFrequency, popDamping),
+		stonePops:    make(map[int]*stonePop),
 	}
 
 	game.Players++
 	game.PlayerConns = append(game.PlayerConns, &m.Conn)
+	if game.Players == 2 {
+		// The clock only starts once both seats are filled; see clockLoop.
+		game.TurnStart = time.Now()
+	}
 
-	return m, []tea.ProgramOption{tea.WithAltScreen()}
-}
+	game.mu.Unlock()
+
+	game.Conn <- JoinMsg{Id: m.Id}
 
-type ModelMenu struct {
+	return m
 }
 
 type Game struct {
 	Id            string
+	Size          int
 	Board         []Cell
 	Cursor        int
 	Last          int
 	Player        Cell
 	WhiteCaptures int
 	BlackCaptures int
-	Players       int
-	Conn          chan tea.Msg
-	PlayerConns   []*chan tea.Msg
+	KoPoint       int
+	Komi          float64
+	Passes        int
 	Moves         []Move
+	Ended         bool
+	Winner        Cell // set by ParseSGF from the SGF RE[] property; Empty if undecided
+
+	// mu guards Players and PlayerConns, which are read and written from
+	// every connected session's own goroutine (joinGame, broadcastLoop,
+	// clockLoop) as players join and leave.
+	mu          sync.Mutex
+	Players     int
+	Conn        chan tea.Msg
+	PlayerConns []*chan tea.Msg
+
+	// Time control. WhiteTimeMs/BlackTimeMs count down main time until it
+	// reaches zero, at which point the side in question drops into
+	// byo-yomi: WhiteInByoyomi/BlackInByoyomi flips on and the clock
+	// instead tracks time left in the current byo-yomi period, resetting
+	// to ByoyomiMs every move until WhitePeriods/BlackPeriods runs out.
+	WhiteTimeMs    int64
+	BlackTimeMs    int64
+	MainTimeMs     int64
+	ByoyomiMs      int64
+	ByoyomiPeriods int
+	WhitePeriods   int
+	BlackPeriods   int
+	WhiteInByoyomi bool
+	BlackInByoyomi bool
+	TurnStart      time.Time
 }
 
 type Move struct {
-	Turn   int
-	Player Cell
-	NRow   int
-	NCol   int
-	Ctime  uint64
+	Turn      int
+	Player    Cell
+	NRow      int
+	NCol      int
+	Ctime     uint64
+	Pass      bool
+	ElapsedMs int64 // time spent on the clock thinking about this move
+	Captured  []int // points removed from the board by this move, if any
 }
 
-func NewGame(id string, db *sql.DB) Game {
-	_, err := db.Exec("INSERT INTO games (id, bsize, white, black, creation) VALUES (?, ?, ?, ?, ?)",
-		id, BOARD_SIZE, "White", "Black", time.Now().UTC().Unix())
+const (
+	defaultKomi = 6.5
+
+	defaultMainTimeMs     = int64(10 * 60 * 1000)
+	defaultByoyomiMs      = int64(30 * 1000)
+	defaultByoyomiPeriods = 3
+)
+
+func NewGame(id string, db *sql.DB, size int) Game {
+	_, err := db.Exec("INSERT INTO games (id, bsize, white, black, creation, komi, main_time_ms, byoyomi_ms, byoyomi_periods) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, size, "White", "Black", time.Now().UTC().Unix(), defaultKomi, defaultMainTimeMs, defaultByoyomiMs, defaultByoyomiPeriods)
 	if err != nil {
 		return Game{}
 	}
 
 	return Game{
-		Id:            id,
-		Board:         make([]Cell, BOARD_SIZE*BOARD_SIZE),
-		Cursor:        -1,
-		Last:          -1,
-		Player:        White,
-		WhiteCaptures: 0,
-		BlackCaptures: 0,
-		Players:       0,
-		Conn:          make(chan tea.Msg, 3),
+		Id:             id,
+		Size:           size,
+		Board:          make([]Cell, size*size),
+		Cursor:         -1,
+		Last:           -1,
+		Player:         White,
+		WhiteCaptures:  0,
+		BlackCaptures:  0,
+		KoPoint:        -1,
+		Komi:           defaultKomi,
+		Passes:         0,
+		Players:        0,
+		Conn:           make(chan tea.Msg, 3),
+		WhiteTimeMs:    defaultMainTimeMs,
+		BlackTimeMs:    defaultMainTimeMs,
+		MainTimeMs:     defaultMainTimeMs,
+		ByoyomiMs:      defaultByoyomiMs,
+		ByoyomiPeriods: defaultByoyomiPeriods,
+		WhitePeriods:   defaultByoyomiPeriods,
+		BlackPeriods:   defaultByoyomiPeriods,
+		TurnStart:      time.Now(),
 	}
 }
 
-func EndGame(id string, db *sql.DB) error {
-	game, ok := games[id]
-	if !ok {
-		return errors.New("game not found")
+// clockFields returns pointers to the clock state tracked for color, or
+// all nils for Spectator.
+func (g *Game) clockFields(color Cell) (remaining *int64, periods *int, inByoyomi *bool) {
+	switch color {
+	case White:
+		return &g.WhiteTimeMs, &g.WhitePeriods, &g.WhiteInByoyomi
+	case Black:
+		return &g.BlackTimeMs, &g.BlackPeriods, &g.BlackInByoyomi
+	default:
+		return nil, nil, nil
+	}
+}
+
+// advanceClock deducts elapsedMs, the time mover spent on the move it
+// just made, from mover's clock. Running out of main time drops the
+// clock into byo-yomi, where every move resets it to a full ByoyomiMs
+// period; overrunning a period spends one of the remaining periods, and
+// overrunning the last period reports a loss on time.
+func advanceClock(game *Game, mover Cell, elapsedMs int64) (timedOut bool) {
+	remaining, periods, inByoyomi := game.clockFields(mover)
+	if remaining == nil {
+		return false
+	}
+
+	left := *remaining - elapsedMs
+	for left <= 0 {
+		if *inByoyomi {
+			if *periods <= 0 {
+				*remaining = 0
+				return true
+			}
+			*periods--
+		}
+		*inByoyomi = true
+		left += game.ByoyomiMs
+	}
+
+	if *inByoyomi {
+		*remaining = game.ByoyomiMs
+	} else {
+		*remaining = left
 	}
 
+	return false
+}
+
+// clockLoop broadcasts the live-ticking clock for whichever side is to
+// move and forfeits the game the instant that side's time budget (its
+// current clock plus one full period per byo-yomi period left, since
+// dropping into byo-yomi itself is free) runs out without a move.
+func clockLoop(game *Game, db *sql.DB) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if game.Ended {
+			return
+		}
+
+		game.mu.Lock()
+		players := game.Players
+		game.mu.Unlock()
+		if players < 2 {
+			// Don't dock the opening player's time while waiting for an
+			// opponent to join; TurnStart is reset once the second seat
+			// fills (see joinGame).
+			continue
+		}
+
+		if game.Player != White && game.Player != Black {
+			continue
+		}
+
+		remaining, periods, inByoyomi := game.clockFields(game.Player)
+		elapsed := time.Since(game.TurnStart).Milliseconds()
+		budget := *remaining + int64(*periods)*game.ByoyomiMs - elapsed
+		if !*inByoyomi {
+			// the first byo-yomi period, entered for free (see advanceClock)
+			budget += game.ByoyomiMs
+		}
+
+		if budget <= 0 {
+			if err := ForfeitGame(game.Id, game.Player.Opponent(), db); err != nil {
+				log.Error("Failed to forfeit game on time", "game_id", game.Id, "error", err)
+			}
+			return
+		}
+
+		whiteMs, whitePeriods := liveClock(game, White)
+		blackMs, blackPeriods := liveClock(game, Black)
+		periodsLeft := whitePeriods
+		if game.Player == Black {
+			periodsLeft = blackPeriods
+		}
+
+		game.Conn <- ClockMsg{WhiteMs: whiteMs, BlackMs: blackMs, Periods: periodsLeft}
+	}
+}
+
+// liveClock reports color's displayed remaining time and byo-yomi
+// periods. The clock is frozen at its stored value unless color is to
+// move, in which case time elapsed since TurnStart is subtracted.
+func liveClock(game *Game, color Cell) (ms int64, periods int) {
+	remaining, periodsLeft, _ := game.clockFields(color)
+	if game.Player != color {
+		return *remaining, *periodsLeft
+	}
+	return *remaining - time.Since(game.TurnStart).Milliseconds(), *periodsLeft
+}
+
+// persistMoves writes game's recorded moves and captures to the database.
+func persistMoves(id string, game *Game, db *sql.DB) error {
 	for _, move := range game.Moves {
-		_, err := db.Exec("INSERT INTO moves (game_id, turn, player, nrow, ncol, ctime) VALUES (?, ?, ?, ?, ?, ?)",
-			id, move.Turn, move.Player, move.NRow, move.NCol, move.Ctime)
+		_, err := db.Exec("INSERT INTO moves (game_id, turn, player, nrow, ncol, ctime, pass, elapsed_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			id, move.Turn, move.Player, move.NRow, move.NCol, move.Ctime, move.Pass, move.ElapsedMs)
 		if err != nil {
 			return err
 		}
+
+		for _, point := range move.Captured {
+			_, err := db.Exec("INSERT INTO captures (game_id, turn, point) VALUES (?, ?, ?)",
+				id, move.Turn, point)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func EndGame(id string, db *sql.DB) error {
+	gamesMu.RLock()
+	game, ok := games[id]
+	gamesMu.RUnlock()
+	if !ok {
+		return errors.New("game not found")
+	}
+	if game.Ended {
+		return nil
 	}
+	game.Ended = true
+
+	if err := persistMoves(id, game, db); err != nil {
+		return err
+	}
+
+	score := rules.FinalScore(game.Board, game.Size, game.WhiteCaptures, game.BlackCaptures, game.Komi)
 
 	game.Conn <- EndMsg{
-		GameId: id,
-		Winner: game.Player,
+		GameId:     id,
+		Winner:     score.Winner,
+		WhiteScore: score.WhiteScore,
+		BlackScore: score.BlackScore,
+		Territory:  rules.Territory(game.Board, game.Size),
 	}
 
-	_, err := db.Exec("UPDATE games SET winner = ?, ended = ? WHERE id = ?", game.Player, time.Now().UTC().Unix(), id)
+	_, err := db.Exec("UPDATE games SET winner = ?, ended = ?, white_score = ?, black_score = ? WHERE id = ?",
+		score.Winner, time.Now().UTC().Unix(), score.WhiteScore, score.BlackScore, id)
 	if err != nil {
 		return err
 	}
 
-	log.Info("Game ended", "game_id", id, "winner", game.Player)
+	log.Info("Game ended", "game_id", id, "winner", score.Winner, "white", score.WhiteScore, "black", score.BlackScore)
 
 	return nil
 }
 
-const BOARD_SIZE = 9 // Go board is 19x19
-// 13 9
+// ForfeitGame ends the game immediately because winner's opponent ran out
+// of time. Unlike EndGame it does not take the winner from territory
+// scoring, since the clock decided the outcome rather than the board; the
+// territory and scores are still computed and reported.
+func ForfeitGame(id string, winner Cell, db *sql.DB) error {
+	gamesMu.RLock()
+	game, ok := games[id]
+	gamesMu.RUnlock()
+	if !ok {
+		return errors.New("game not found")
+	}
+	if game.Ended {
+		return nil
+	}
+	game.Ended = true
+
+	if err := persistMoves(id, game, db); err != nil {
+		return err
+	}
 
-type Cell int
+	score := rules.FinalScore(game.Board, game.Size, game.WhiteCaptures, game.BlackCaptures, game.Komi)
+
+	game.Conn <- EndMsg{
+		GameId:     id,
+		Winner:     winner,
+		WhiteScore: score.WhiteScore,
+		BlackScore: score.BlackScore,
+		Territory:  rules.Territory(game.Board, game.Size),
+	}
+
+	_, err := db.Exec("UPDATE games SET winner = ?, ended = ?, white_score = ?, black_score = ? WHERE id = ?",
+		winner, time.Now().UTC().Unix(), score.WhiteScore, score.BlackScore, id)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Game forfeited on time", "game_id", id, "winner", winner)
+
+	return nil
+}
+
+const BOARD_SIZE = 9 // default board size offered in the menu
+// 13 9 19
+
+// Cell and its values are defined in the rules package, which owns the
+// capture/ko/suicide engine; main just re-exports them so the rest of
+// the game code doesn't need to import rules directly for board state.
+type Cell = rules.Cell
 
 const (
-	Empty Cell = iota
-	White
-	Black
+	Empty     = rules.Empty
+	White     = rules.White
+	Black     = rules.Black
+	Spectator = rules.Spectator
 )
 
 type SendMsg struct {
 	Id int
 }
 
+// JoinMsg is broadcast to the other connections on a game when a new
+// player or spectator takes a seat at the table.
+type JoinMsg struct {
+	Id int
+}
+
+// LeaveMsg is broadcast to the other connections on a game when a player
+// or spectator disconnects.
+type LeaveMsg struct {
+	Id int
+}
+
 type EndMsg struct {
-	GameId string
-	Winner Cell
+	GameId     string
+	Winner     Cell
+	WhiteScore float64
+	BlackScore float64
+	Territory  []Cell
+}
+
+// ClockMsg is broadcast once a second with the live-ticking time for
+// whichever side is to move. Periods is that side's remaining byo-yomi
+// periods.
+type ClockMsg struct {
+	WhiteMs int64
+	BlackMs int64
+	Periods int
 }
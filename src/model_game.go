@@ -1,12 +1,28 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/harmonica"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/log"
+
+	"github.com/JoeyShapiro/GoGo/src/rules"
+)
+
+const (
+	animFPS     = 45
+	animEpsilon = 0.01
+
+	cursorFrequency = 6.0
+	cursorDamping   = 0.8
+
+	popFrequency = 8.0
+	popDamping   = 0.3
 )
 
 type ModelGame struct {
@@ -18,6 +34,91 @@ type ModelGame struct {
 	GameId   string
 	Player   Cell
 	Conn     chan tea.Msg
+	notice   string
+	final    *EndMsg
+	clock    *ClockMsg
+
+	animating              bool
+	lastSeen               int
+	cursorSpring           harmonica.Spring
+	cursorX, cursorY       float64
+	cursorVelX, cursorVelY float64
+	stoneSpring            harmonica.Spring
+	stonePops              map[int]*stonePop
+}
+
+// stonePop drives the "pop in" animation for a freshly placed stone: its
+// intensity springs from 0 to 1 and the rendered glyph picks a dim,
+// medium, or bright shade based on how far along it is.
+type stonePop struct {
+	intensity float64
+	velocity  float64
+}
+
+type animTickMsg struct{}
+
+func animTick() tea.Cmd {
+	return tea.Tick(time.Second/animFPS, func(time.Time) tea.Msg {
+		return animTickMsg{}
+	})
+}
+
+func cursorTarget(game *Game) (x, y float64) {
+	if game.Cursor < 0 {
+		return -1, -1
+	}
+	return float64(game.Cursor % game.Size), float64(game.Cursor / game.Size)
+}
+
+// stoneGlyph renders a stone at a brightness that climbs from dim to
+// bright as intensity springs from 0 to 1, giving a freshly placed stone a
+// "pop in" feel instead of appearing at full brightness instantly.
+func stoneGlyph(color Cell, intensity float64, background lipgloss.Style, hollow bool) string {
+	glyph := "●"
+	if hollow {
+		glyph = "○"
+	}
+
+	var shade string
+	switch {
+	case color == White && intensity < 0.33:
+		shade = "#cfa97c"
+	case color == White && intensity < 0.66:
+		shade = "#eeeeee"
+	case color == White:
+		shade = "#ffffff"
+	case color == Black && intensity < 0.33:
+		shade = "#8a6f4f"
+	case color == Black && intensity < 0.66:
+		shade = "#333333"
+	default:
+		shade = "#000000"
+	}
+
+	return background.Foreground(lipgloss.Color(shade)).Render(glyph)
+}
+
+// formatClock renders a clock reading as mm:ss, clamping negative values
+// (the display can dip below zero for an instant before a timeout is
+// processed) to 00:00.
+func formatClock(ms int64) string {
+	if ms < 0 {
+		ms = 0
+	}
+	seconds := ms / 1000
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
+
+func clampRound(v float64, size int) int {
+	i := int(math.Round(v))
+	switch {
+	case i < 0:
+		return 0
+	case i >= size:
+		return size - 1
+	default:
+		return i
+	}
 }
 
 func listenCmd(m ModelGame) tea.Cmd {
@@ -27,6 +128,70 @@ func listenCmd(m ModelGame) tea.Cmd {
 	}
 }
 
+// withListen notices any new move on the shared game state, kicks off the
+// animation tick loop if something needs to move, and always keeps
+// listening for the next message on m.Conn.
+func (m ModelGame) withListen() (tea.Model, tea.Cmd) {
+	gamesMu.RLock()
+	game, ok := games[m.GameId]
+	gamesMu.RUnlock()
+	if !ok {
+		return m, listenCmd(m)
+	}
+
+	if game.Last != m.lastSeen && game.Last >= 0 {
+		m.lastSeen = game.Last
+		m.stonePops[game.Last] = &stonePop{}
+	}
+
+	targetX, targetY := cursorTarget(game)
+	pending := len(m.stonePops) > 0 ||
+		math.Abs(targetX-m.cursorX) > animEpsilon || math.Abs(targetY-m.cursorY) > animEpsilon
+
+	if !m.animating && pending {
+		m.animating = true
+		return m, tea.Batch(listenCmd(m), animTick())
+	}
+
+	return m, listenCmd(m)
+}
+
+// updateAnimations advances the cursor and stone-pop springs by one tick
+// and keeps ticking only while something is still moving, so idle SSH
+// sessions aren't woken 45 times a second for nothing.
+func (m ModelGame) updateAnimations() (tea.Model, tea.Cmd) {
+	gamesMu.RLock()
+	game, ok := games[m.GameId]
+	gamesMu.RUnlock()
+	if !ok {
+		m.animating = false
+		return m, nil
+	}
+
+	targetX, targetY := cursorTarget(game)
+	m.cursorX, m.cursorVelX = m.cursorSpring.Update(m.cursorX, m.cursorVelX, targetX)
+	m.cursorY, m.cursorVelY = m.cursorSpring.Update(m.cursorY, m.cursorVelY, targetY)
+
+	moving := math.Abs(m.cursorVelX) > animEpsilon || math.Abs(m.cursorVelY) > animEpsilon ||
+		math.Abs(targetX-m.cursorX) > animEpsilon || math.Abs(targetY-m.cursorY) > animEpsilon
+
+	for p, pop := range m.stonePops {
+		pop.intensity, pop.velocity = m.stoneSpring.Update(pop.intensity, pop.velocity, 1)
+		if math.Abs(pop.velocity) < animEpsilon && math.Abs(1-pop.intensity) < animEpsilon {
+			delete(m.stonePops, p)
+			continue
+		}
+		moving = true
+	}
+
+	if !moving {
+		m.animating = false
+		return m, nil
+	}
+
+	return m, animTick()
+}
+
 func (m ModelGame) Init() tea.Cmd {
 	return nil
 }
@@ -34,20 +199,43 @@ func (m ModelGame) Init() tea.Cmd {
 func (m ModelGame) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case SendMsg:
-		return m, listenCmd(m)
+		return m.withListen()
+	case JoinMsg:
+		m.notice = "opponent joined"
+		return m.withListen()
+	case LeaveMsg:
+		m.notice = "opponent disconnected"
+		return m.withListen()
+	case EndMsg:
+		m.final = &msg
+		return m.withListen()
+	case ClockMsg:
+		m.clock = &msg
+		return m.withListen()
+	case animTickMsg:
+		return m.updateAnimations()
 	case tea.WindowSizeMsg:
 		m.height = msg.Height
 		m.width = msg.Width
 	case tea.KeyMsg:
+		gamesMu.RLock()
 		game, ok := games[m.GameId]
+		gamesMu.RUnlock()
 		if !ok {
 			log.Error("Game not found", "game_id", m.GameId)
 			return m, tea.Quit
 		}
 
-		switch msg.String() {
-		case "q", "ctrl+c":
+		if msg.String() == "q" || msg.String() == "ctrl+c" {
+			game.Conn <- LeaveMsg{Id: m.Id}
 			return m, tea.Quit
+		}
+
+		if m.final != nil {
+			return m.withListen()
+		}
+
+		switch msg.String() {
 		case "a", "left":
 			if game.Player == m.Player && game.Cursor > 0 {
 				game.Cursor--
@@ -57,47 +245,106 @@ func (m ModelGame) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				game.Cursor++
 			}
 		case "w", "up":
-			if game.Player == m.Player && game.Cursor-BOARD_SIZE >= 0 {
-				game.Cursor -= BOARD_SIZE
+			if game.Player == m.Player && game.Cursor-game.Size >= 0 {
+				game.Cursor -= game.Size
 			}
 		case "s", "down":
-			if game.Player == m.Player && game.Cursor+BOARD_SIZE < len(game.Board) {
-				game.Cursor += BOARD_SIZE
+			if game.Player == m.Player && game.Cursor+game.Size < len(game.Board) {
+				game.Cursor += game.Size
 			}
-		case "tab": // tab to pass
-			if m.Player == White {
-				m.Player = Black
-			} else {
-				m.Player = White
+		case "tab": // pass
+			if game.Player == m.Player {
+				elapsed := time.Since(game.TurnStart).Milliseconds()
+				if advanceClock(game, game.Player, elapsed) {
+					if err := ForfeitGame(m.GameId, game.Player.Opponent(), db); err != nil {
+						log.Error("Failed to end game on time", "game_id", m.GameId, "error", err)
+					}
+					break
+				}
+
+				game.Moves = append(game.Moves, Move{
+					Turn:      len(game.Moves),
+					Player:    game.Player,
+					NRow:      -1,
+					NCol:      -1,
+					Ctime:     uint64(time.Now().UTC().Unix()),
+					Pass:      true,
+					ElapsedMs: elapsed,
+				})
+				game.Passes++
+
+				if game.Player == White {
+					game.Player = Black
+				} else {
+					game.Player = White
+				}
+				game.TurnStart = time.Now()
+
+				if game.Passes >= 2 {
+					if err := EndGame(m.GameId, db); err != nil {
+						log.Error("Failed to end game", "game_id", m.GameId, "error", err)
+					}
+				}
 			}
 		case " ":
 			if game.Player == m.Player && game.Cursor >= 0 && game.Cursor < len(game.Board) {
-				game.Board[game.Cursor] = game.Player
+				move := rules.Move{Point: game.Cursor, Color: game.Player}
+				if err := rules.LegalMove(game.Board, game.Size, move, game.KoPoint); err != nil {
+					m.notice = err.Error()
+					break
+				}
+
+				elapsed := time.Since(game.TurnStart).Milliseconds()
+				if advanceClock(game, game.Player, elapsed) {
+					if err := ForfeitGame(m.GameId, game.Player.Opponent(), db); err != nil {
+						log.Error("Failed to end game on time", "game_id", m.GameId, "error", err)
+					}
+					break
+				}
+
+				newBoard, captured, newKo := rules.ApplyMove(game.Board, game.Size, move)
+				game.Board = newBoard
+				game.KoPoint = newKo
+
+				switch game.Player {
+				case White:
+					game.WhiteCaptures += len(captured)
+				case Black:
+					game.BlackCaptures += len(captured)
+				}
+
 				game.Moves = append(game.Moves, Move{
-					Turn:   len(game.Moves),
-					Player: game.Player,
-					NRow:   game.Cursor / BOARD_SIZE,
-					NCol:   game.Cursor % BOARD_SIZE,
-					Ctime:  uint64(time.Now().UTC().Unix()),
+					Turn:      len(game.Moves),
+					Player:    game.Player,
+					NRow:      game.Cursor / game.Size,
+					NCol:      game.Cursor % game.Size,
+					Ctime:     uint64(time.Now().UTC().Unix()),
+					ElapsedMs: elapsed,
+					Captured:  captured,
 				})
 
 				game.Last = game.Cursor
+				game.Passes = 0
+				m.notice = ""
 				if game.Player == White {
 					game.Player = Black
 				} else {
 					game.Player = White
 				}
+				game.TurnStart = time.Now()
 			}
 		}
 
 		game.Conn <- SendMsg{Id: m.Id}
 	}
 
-	return m, listenCmd(m)
+	return m.withListen()
 }
 
 func (m ModelGame) View() string {
+	gamesMu.RLock()
 	game, ok := games[m.GameId]
+	gamesMu.RUnlock()
 	if !ok {
 		log.Error("Game not found", "game_id", m.GameId)
 		return "Game not found"
@@ -112,17 +359,36 @@ func (m ModelGame) View() string {
 	cursorBlack := background.Foreground(lipgloss.Color("#000000")).Render("○")
 	cursorWhite := background.Foreground(lipgloss.Color("#ffffff")).Render("○")
 	selected := m.txtStyle.Foreground(lipgloss.Color("#ff0000"))
+	dameDot := background.Foreground(lipgloss.Color("#888888")).Render("·")
+	whiteDot := background.Foreground(lipgloss.Color("#dddddd")).Render("·")
+	blackDot := background.Foreground(lipgloss.Color("#444444")).Render("·")
+
+	if m.clock != nil {
+		b.WriteString("White " + formatClock(m.clock.WhiteMs))
+		b.WriteString("  Black " + formatClock(m.clock.BlackMs))
+		if m.clock.Periods < game.ByoyomiPeriods {
+			b.WriteString(fmt.Sprintf("  (%d periods left)", m.clock.Periods))
+		}
+		b.WriteRune('\n')
+	}
+
+	if m.notice != "" {
+		b.WriteString(m.txtStyle.Faint(true).Render(m.notice))
+		b.WriteRune('\n')
+	}
 
 	x := -1
 	y := -1
+	animCursor := -1
 	if game.Cursor > -1 {
-		x = game.Cursor % BOARD_SIZE
-		y = game.Cursor/BOARD_SIZE + 1
+		x = clampRound(m.cursorX, game.Size)
+		y = clampRound(m.cursorY, game.Size) + 1
+		animCursor = (y-1)*game.Size + x
 	}
 
 	// top margin coordinates
 	b.WriteRune(' ')
-	for i := range BOARD_SIZE {
+	for i := range game.Size {
 		margin := rune(i + 65)
 		if x > -1 && i == x {
 			b.WriteString(selected.Render(string(margin)))
@@ -132,9 +398,9 @@ func (m ModelGame) View() string {
 	}
 	for i := range game.Board {
 		// left margin coordinates
-		if i%BOARD_SIZE == 0 {
+		if i%game.Size == 0 {
 			b.WriteRune('\n')
-			row := i/BOARD_SIZE + 1
+			row := i/game.Size + 1
 			margin := rune(row + 48)
 			if y > -1 && row == y {
 				b.WriteString(selected.Render(string(margin)))
@@ -143,7 +409,7 @@ func (m ModelGame) View() string {
 			}
 		}
 
-		if i == game.Cursor {
+		if i == animCursor {
 			switch game.Player {
 			case White:
 				b.WriteString(cursorWhite)
@@ -151,11 +417,24 @@ func (m ModelGame) View() string {
 				b.WriteString(cursorBlack)
 			}
 		} else if i == game.Last {
-			switch game.Board[i] {
+			if pop, ok := m.stonePops[i]; ok {
+				b.WriteString(stoneGlyph(game.Board[i], pop.intensity, background, true))
+			} else {
+				switch game.Board[i] {
+				case White:
+					b.WriteString(cursorWhite)
+				case Black:
+					b.WriteString(cursorBlack)
+				}
+			}
+		} else if m.final != nil && game.Board[i] == Empty && i < len(m.final.Territory) {
+			switch m.final.Territory[i] {
 			case White:
-				b.WriteString(cursorWhite)
+				b.WriteString(whiteDot)
 			case Black:
-				b.WriteString(cursorBlack)
+				b.WriteString(blackDot)
+			default:
+				b.WriteString(dameDot)
 			}
 		} else {
 			switch game.Board[i] {
@@ -171,8 +450,8 @@ func (m ModelGame) View() string {
 		}
 
 		// right margin coordinates
-		if i%BOARD_SIZE == BOARD_SIZE-1 {
-			row := i/BOARD_SIZE + 1
+		if i%game.Size == game.Size-1 {
+			row := i/game.Size + 1
 			margin := rune(row + 48)
 			if y > -1 && row == y {
 				b.WriteString(selected.Render(string(margin)))
@@ -185,7 +464,7 @@ func (m ModelGame) View() string {
 	b.WriteRune('\n')
 	// bottom margin coordinates
 	b.WriteRune(' ')
-	for i := range BOARD_SIZE {
+	for i := range game.Size {
 		margin := rune(i + 65)
 		if x > -1 && i == x {
 			b.WriteString(selected.Render(string(margin)))
@@ -194,5 +473,18 @@ func (m ModelGame) View() string {
 		}
 	}
 
+	if m.final != nil {
+		b.WriteRune('\n')
+		b.WriteString(fmt.Sprintf("\nWhite %.1f - Black %.1f", m.final.WhiteScore, m.final.BlackScore))
+		switch m.final.Winner {
+		case White:
+			b.WriteString(" (White wins)")
+		case Black:
+			b.WriteString(" (Black wins)")
+		default:
+			b.WriteString(" (tie)")
+		}
+	}
+
 	return b.String()
 }
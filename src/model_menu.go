@@ -0,0 +1,316 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/google/uuid"
+)
+
+type menuMode int
+
+const (
+	menuList menuMode = iota
+	menuCreate
+	menuUpload
+)
+
+// lobbyEntry is a row in the game list, merged from the in-memory games
+// map (for live player counts) and the SQLite games table (for games
+// that have finished and are only available to spectate/replay).
+type lobbyEntry struct {
+	Id      string
+	Size    int
+	Players int
+	Ended   bool
+}
+
+type gamesLoadedMsg []lobbyEntry
+
+type ModelMenu struct {
+	term     string
+	width    int
+	height   int
+	txtStyle lipgloss.Style
+
+	mode    menuMode
+	cursor  int
+	entries []lobbyEntry
+
+	sizes      []int
+	sizeCursor int
+	color      Cell
+
+	upload    string
+	uploadErr string
+}
+
+func loadGamesCmd() tea.Msg {
+	gamesMu.RLock()
+	entries := make([]lobbyEntry, 0, len(games))
+	seen := make(map[string]bool, len(games))
+
+	for id, game := range games {
+		game.mu.Lock()
+		players := game.Players
+		game.mu.Unlock()
+		entries = append(entries, lobbyEntry{Id: id, Size: game.Size, Players: players})
+		seen[id] = true
+	}
+	gamesMu.RUnlock()
+
+	if db != nil {
+		rows, err := db.Query("SELECT id, bsize FROM games WHERE ended IS NOT NULL")
+		if err != nil {
+			log.Error("Failed to list finished games", "error", err)
+			return gamesLoadedMsg(entries)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id string
+			var size int
+			if err := rows.Scan(&id, &size); err != nil {
+				log.Error("Failed to scan game row", "error", err)
+				continue
+			}
+			if seen[id] {
+				continue
+			}
+			entries = append(entries, lobbyEntry{Id: id, Size: size, Ended: true})
+		}
+	}
+
+	return gamesLoadedMsg(entries)
+}
+
+func (m ModelMenu) Init() tea.Cmd {
+	return loadGamesCmd
+}
+
+func (m ModelMenu) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case gamesLoadedMsg:
+		m.entries = []lobbyEntry(msg)
+		return m, nil
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.width = msg.Width
+		return m, nil
+	case tea.KeyMsg:
+		switch m.mode {
+		case menuList:
+			return m.updateList(msg)
+		case menuCreate:
+			return m.updateCreate(msg)
+		case menuUpload:
+			return m.updateUpload(msg)
+		}
+	}
+
+	return m, nil
+}
+
+// row 0 is always the "new game" option, rows 1..n are existing games.
+func (m ModelMenu) updateList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "u":
+		m.mode = menuUpload
+		m.upload = ""
+		m.uploadErr = ""
+		return m, nil
+	case "w", "up":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "s", "down":
+		if m.cursor < len(m.entries) {
+			m.cursor++
+		}
+	case "enter", " ":
+		if m.cursor == 0 {
+			m.mode = menuCreate
+			return m, nil
+		}
+
+		entry := m.entries[m.cursor-1]
+		if entry.Ended {
+			replay := ModelReplay{txtStyle: m.txtStyle, term: m.term, width: m.width, height: m.height, GameId: entry.Id}
+			return replay, replay.Init()
+		}
+
+		gamesMu.RLock()
+		game, ok := games[entry.Id]
+		gamesMu.RUnlock()
+		if !ok {
+			log.Error("Game not found", "game_id", entry.Id)
+			return m, nil
+		}
+
+		gm := joinGame(entry.Id, game, m.txtStyle, m.term, m.width, m.height)
+		return gm, gm.Init()
+	}
+
+	return m, nil
+}
+
+// updateUpload accumulates a pasted SGF game tree and, on enter, parses it
+// and records it as a finished game available for replay.
+func (m ModelMenu) updateUpload(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		m.mode = menuList
+		return m, nil
+	case "enter":
+		game, err := ParseSGF(strings.NewReader(m.upload))
+		if err != nil {
+			m.uploadErr = err.Error()
+			return m, nil
+		}
+
+		game.Id = uuid.New().String()
+		_, err = db.Exec("INSERT INTO games (id, bsize, white, black, creation, ended, winner) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			game.Id, game.Size, "White", "Black", time.Now().UTC().Unix(), time.Now().UTC().Unix(), game.Winner)
+		if err != nil {
+			m.uploadErr = err.Error()
+			return m, nil
+		}
+
+		for _, move := range game.Moves {
+			if _, err := db.Exec("INSERT INTO moves (game_id, turn, player, nrow, ncol, ctime, pass, elapsed_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+				game.Id, move.Turn, move.Player, move.NRow, move.NCol, move.Ctime, move.Pass, move.ElapsedMs); err != nil {
+				m.uploadErr = err.Error()
+				return m, nil
+			}
+		}
+
+		m.mode = menuList
+		return m, loadGamesCmd
+	case "backspace":
+		if len(m.upload) > 0 {
+			m.upload = m.upload[:len(m.upload)-1]
+		}
+		return m, nil
+	default:
+		if len(msg.Runes) > 0 {
+			m.upload += msg.String()
+		}
+		return m, nil
+	}
+}
+
+func (m ModelMenu) updateCreate(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		m.mode = menuList
+		return m, nil
+	case "a", "left":
+		if m.sizeCursor > 0 {
+			m.sizeCursor--
+		}
+	case "d", "right":
+		if m.sizeCursor < len(m.sizes)-1 {
+			m.sizeCursor++
+		}
+	case "w", "up", "s", "down":
+		if m.color == Black {
+			m.color = White
+		} else {
+			m.color = Black
+		}
+	case "enter", " ":
+		id := uuid.New().String()
+		game := NewGame(id, db, m.sizes[m.sizeCursor])
+		gamesMu.Lock()
+		games[id] = &game
+		gamesMu.Unlock()
+		go broadcastLoop(&game)
+		go clockLoop(&game, db)
+
+		if m.color == Black {
+			// seat the creator as Black by pre-filling the White slot with
+			// an unclaimed nil connection so joinGame hands out Black next;
+			// sendPlayer skips nil slots instead of broadcasting into a
+			// channel nothing will ever read from.
+			game.mu.Lock()
+			game.Players = 1
+			game.PlayerConns = append(game.PlayerConns, nil)
+			game.mu.Unlock()
+		}
+
+		gm := joinGame(id, &game, m.txtStyle, m.term, m.width, m.height)
+		return gm, gm.Init()
+	}
+
+	return m, nil
+}
+
+func (m ModelMenu) View() string {
+	var b strings.Builder
+
+	switch m.mode {
+	case menuCreate:
+		b.WriteString("New game\n\n")
+		b.WriteString("size: ")
+		for i, size := range m.sizes {
+			if i == m.sizeCursor {
+				b.WriteString(m.txtStyle.Foreground(lipgloss.Color("#ff0000")).Render(strconv.Itoa(size)))
+			} else {
+				b.WriteString(strconv.Itoa(size))
+			}
+			b.WriteRune(' ')
+		}
+		b.WriteRune('\n')
+		b.WriteString("color: ")
+		if m.color == Black {
+			b.WriteString("Black")
+		} else {
+			b.WriteString("White")
+		}
+		b.WriteString("\n\nenter to create, q to go back")
+		return b.String()
+	case menuUpload:
+		b.WriteString("Paste an SGF game tree, then press enter\n\n")
+		b.WriteString(m.upload)
+		if m.uploadErr != "" {
+			b.WriteString("\n\n" + m.txtStyle.Foreground(lipgloss.Color("#ff0000")).Render(m.uploadErr))
+		}
+		b.WriteString("\n\nesc to cancel")
+		return b.String()
+	default:
+		b.WriteString("GoGo — open games\n\n")
+		if m.cursor == 0 {
+			b.WriteString(m.txtStyle.Foreground(lipgloss.Color("#ff0000")).Render("> new game"))
+		} else {
+			b.WriteString("  new game")
+		}
+		b.WriteRune('\n')
+
+		for i, entry := range m.entries {
+			status := "open"
+			switch {
+			case entry.Ended:
+				status = "finished (spectate replay)"
+			case entry.Players >= 2:
+				status = "in progress (spectate)"
+			}
+
+			line := strconv.Itoa(entry.Size) + "x" + strconv.Itoa(entry.Size) + " " + entry.Id[:8] + " - " + status
+			if m.cursor == i+1 {
+				b.WriteString(m.txtStyle.Foreground(lipgloss.Color("#ff0000")).Render("> " + line))
+			} else {
+				b.WriteString("  " + line)
+			}
+			b.WriteRune('\n')
+		}
+
+		b.WriteString("\nw/s to move, enter to join/spectate, u to upload an SGF, q to quit")
+		return b.String()
+	}
+}
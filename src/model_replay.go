@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+
+	"github.com/JoeyShapiro/GoGo/src/rules"
+)
+
+// ModelReplay lets a spectator step forward and backward through the
+// recorded moves of a finished game.
+type ModelReplay struct {
+	term     string
+	width    int
+	height   int
+	txtStyle lipgloss.Style
+
+	GameId string
+	Size   int
+	Moves  []Move
+
+	step  int
+	board []rules.Cell
+}
+
+type replayLoadedMsg struct {
+	size  int
+	moves []Move
+}
+
+func loadReplayCmd(gameId string) tea.Cmd {
+	return func() tea.Msg {
+		var size int
+		if err := db.QueryRow("SELECT bsize FROM games WHERE id = ?", gameId).Scan(&size); err != nil {
+			log.Error("Failed to load game size", "game_id", gameId, "error", err)
+			size = BOARD_SIZE
+		}
+
+		rows, err := db.Query("SELECT turn, player, nrow, ncol, ctime, pass FROM moves WHERE game_id = ? ORDER BY turn", gameId)
+		if err != nil {
+			log.Error("Failed to load moves", "game_id", gameId, "error", err)
+			return replayLoadedMsg{size: size}
+		}
+		defer rows.Close()
+
+		var moves []Move
+		for rows.Next() {
+			var mv Move
+			if err := rows.Scan(&mv.Turn, &mv.Player, &mv.NRow, &mv.NCol, &mv.Ctime, &mv.Pass); err != nil {
+				log.Error("Failed to scan move row", "error", err)
+				continue
+			}
+			moves = append(moves, mv)
+		}
+
+		return replayLoadedMsg{size: size, moves: moves}
+	}
+}
+
+// replayBoard replays moves[:step] from an empty board of the given size,
+// trusting that they were legal when originally played. Pass moves carry
+// no point and are skipped.
+func replayBoard(moves []Move, size, step int) []rules.Cell {
+	board := make([]rules.Cell, size*size)
+	for _, mv := range moves[:step] {
+		if mv.Pass {
+			continue
+		}
+		move := rules.Move{Point: mv.NRow*size + mv.NCol, Color: mv.Player}
+		board, _, _ = rules.ApplyMove(board, size, move)
+	}
+	return board
+}
+
+func (m ModelReplay) Init() tea.Cmd {
+	return loadReplayCmd(m.GameId)
+}
+
+func (m ModelReplay) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case replayLoadedMsg:
+		m.Size = msg.size
+		m.Moves = msg.moves
+		m.step = len(m.Moves)
+		m.board = replayBoard(m.Moves, m.Size, m.step)
+		return m, nil
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.width = msg.Width
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			menu := ModelMenu{txtStyle: m.txtStyle, term: m.term, width: m.width, height: m.height, sizes: []int{9, 13, 19}}
+			return menu, menu.Init()
+		case "left":
+			if m.step > 0 {
+				m.step--
+				m.board = replayBoard(m.Moves, m.Size, m.step)
+			}
+		case "right":
+			if m.step < len(m.Moves) {
+				m.step++
+				m.board = replayBoard(m.Moves, m.Size, m.step)
+			}
+		}
+	}
+
+	return m, nil
+}
+
+func (m ModelReplay) View() string {
+	if m.Size == 0 {
+		return "loading..."
+	}
+
+	var b strings.Builder
+	background := m.txtStyle.Background(lipgloss.Color("#af875f"))
+	empty := background.Foreground(lipgloss.Color("#000000")).Render("┼")
+	white := background.Foreground(lipgloss.Color("#ffffff")).Render("●")
+	black := background.Foreground(lipgloss.Color("#000000")).Render("●")
+
+	for i, cell := range m.board {
+		if i%m.Size == 0 && i > 0 {
+			b.WriteRune('\n')
+		}
+		switch cell {
+		case White:
+			b.WriteString(white)
+		case Black:
+			b.WriteString(black)
+		default:
+			b.WriteString(empty)
+		}
+	}
+
+	b.WriteString("\n\nmove " + strconv.Itoa(m.step) + "/" + strconv.Itoa(len(m.Moves)))
+	b.WriteString("\n←/→ to step, q to return to the menu")
+
+	return b.String()
+}
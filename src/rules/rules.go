@@ -0,0 +1,162 @@
+// Package rules implements the legality and capture rules of Go:
+// liberty counting, captures, suicide, and simple ko.
+package rules
+
+import "errors"
+
+type Cell int
+
+const (
+	Empty Cell = iota
+	White
+	Black
+	Spectator
+)
+
+// Opponent returns the other playing color. It is only meaningful for
+// White and Black.
+func (c Cell) Opponent() Cell {
+	if c == White {
+		return Black
+	}
+	return White
+}
+
+// Move is a single stone placement to be checked or applied against a
+// board. It does not carry the bookkeeping (turn number, timestamp) that
+// the persisted move log needs; that lives alongside the Game type.
+type Move struct {
+	Point int
+	Color Cell
+}
+
+var (
+	ErrOccupied = errors.New("rules: point is occupied")
+	ErrSuicide  = errors.New("rules: move is suicide")
+	ErrKo       = errors.New("rules: move recaptures the ko point")
+)
+
+// LegalMove reports whether move may be played on board without mutating
+// it. koPoint is the point a simple-ko rule currently forbids recapturing
+// on, or -1 if there is none.
+func LegalMove(board []Cell, size int, move Move, koPoint int) error {
+	if move.Point < 0 || move.Point >= len(board) {
+		return errors.New("rules: point out of bounds")
+	}
+	if board[move.Point] != Empty {
+		return ErrOccupied
+	}
+
+	_, captured, _ := ApplyMove(board, size, move)
+
+	if move.Point == koPoint && len(captured) == 1 {
+		return ErrKo
+	}
+
+	if len(captured) == 0 {
+		working := append([]Cell(nil), board...)
+		working[move.Point] = move.Color
+		if libertiesOf(working, size, move.Point) == 0 {
+			return ErrSuicide
+		}
+	}
+
+	return nil
+}
+
+// ApplyMove plays move on a copy of board and returns the resulting
+// board, the points captured from the opponent, and the new ko point (-1
+// if the move did not create one). It does not itself reject illegal
+// moves; call LegalMove first.
+func ApplyMove(board []Cell, size int, move Move) (newBoard []Cell, captured []int, newKo int) {
+	newBoard = append([]Cell(nil), board...)
+	newBoard[move.Point] = move.Color
+	newKo = -1
+
+	opponent := move.Color.Opponent()
+	seen := make(map[int]bool)
+	for _, n := range neighbors(move.Point, size) {
+		if newBoard[n] != opponent || seen[n] {
+			continue
+		}
+
+		group := floodGroup(newBoard, size, n)
+		for _, p := range group {
+			seen[p] = true
+		}
+
+		if libertiesOfGroup(newBoard, size, group) == 0 {
+			for _, p := range group {
+				newBoard[p] = Empty
+			}
+			captured = append(captured, group...)
+		}
+	}
+
+	if len(captured) == 1 {
+		newKo = captured[0]
+	}
+
+	return newBoard, captured, newKo
+}
+
+func neighbors(p, size int) []int {
+	var out []int
+	row, col := p/size, p%size
+	if row > 0 {
+		out = append(out, p-size)
+	}
+	if row < size-1 {
+		out = append(out, p+size)
+	}
+	if col > 0 {
+		out = append(out, p-1)
+	}
+	if col < size-1 {
+		out = append(out, p+1)
+	}
+	return out
+}
+
+// floodGroup returns every point connected to start that shares its
+// color.
+func floodGroup(board []Cell, size, start int) []int {
+	color := board[start]
+	visited := map[int]bool{start: true}
+	stack := []int{start}
+	group := []int{start}
+
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		for _, n := range neighbors(p, size) {
+			if visited[n] || board[n] != color {
+				continue
+			}
+			visited[n] = true
+			stack = append(stack, n)
+			group = append(group, n)
+		}
+	}
+
+	return group
+}
+
+// libertiesOf counts the empty points adjacent to the group containing
+// p.
+func libertiesOf(board []Cell, size, p int) int {
+	return libertiesOfGroup(board, size, floodGroup(board, size, p))
+}
+
+func libertiesOfGroup(board []Cell, size int, group []int) int {
+	liberties := make(map[int]bool)
+	for _, p := range group {
+		for _, n := range neighbors(p, size) {
+			if board[n] == Empty {
+				liberties[n] = true
+			}
+		}
+	}
+	return len(liberties)
+}
@@ -0,0 +1,87 @@
+package rules
+
+import (
+	"errors"
+	"testing"
+)
+
+func emptyBoard(size int) []Cell {
+	return make([]Cell, size*size)
+}
+
+func TestApplyMove_Capture(t *testing.T) {
+	// A lone white stone at (1,1) with its only liberty at (1,2).
+	size := 3
+	board := emptyBoard(size)
+	board[1] = Black // (0,1)
+	board[3] = Black // (1,0)
+	board[4] = White // (1,1)
+	board[7] = Black // (2,1)
+
+	move := Move{Point: 5, Color: Black} // (1,2)
+	newBoard, captured, newKo := ApplyMove(board, size, move)
+
+	if len(captured) != 1 || captured[0] != 4 {
+		t.Fatalf("captured = %v, want [4]", captured)
+	}
+	if newBoard[4] != Empty {
+		t.Fatalf("captured point still occupied: %v", newBoard[4])
+	}
+	if newKo != 4 {
+		t.Fatalf("newKo = %d, want 4", newKo)
+	}
+}
+
+func TestLegalMove_Suicide(t *testing.T) {
+	// Black stone at (1,1) is entirely surrounded by White with no
+	// captures resulting, so playing there is suicide.
+	size := 3
+	board := emptyBoard(size)
+	board[1] = White // (0,1)
+	board[3] = White // (1,0)
+	board[5] = White // (1,2)
+	board[7] = White // (2,1)
+
+	err := LegalMove(board, size, Move{Point: 4, Color: Black}, -1)
+	if !errors.Is(err, ErrSuicide) {
+		t.Fatalf("err = %v, want ErrSuicide", err)
+	}
+}
+
+// koPosition builds a board where a single white stone at point 12 has its
+// only liberty at point 11, and point 11's only liberty (once Black plays
+// there) is point 12 - the minimal shape needed to fight a ko.
+func koPosition(size int) []Cell {
+	board := emptyBoard(size)
+	board[7] = Black  // (1,2)
+	board[17] = Black // (3,2)
+	board[13] = Black // (2,3)
+	board[12] = White // (2,2), the contested stone
+	board[6] = White  // (1,1)
+	board[16] = White // (3,1)
+	board[10] = White // (2,0)
+	return board
+}
+
+func TestLegalMove_Ko(t *testing.T) {
+	size := 5
+	board := koPosition(size)
+
+	board, captured, koPoint := ApplyMove(board, size, Move{Point: 11, Color: Black})
+	if len(captured) != 1 || captured[0] != 12 {
+		t.Fatalf("captured = %v, want [12]", captured)
+	}
+	if koPoint != 12 {
+		t.Fatalf("koPoint = %d, want 12", koPoint)
+	}
+
+	err := LegalMove(board, size, Move{Point: 12, Color: White}, koPoint)
+	if !errors.Is(err, ErrKo) {
+		t.Fatalf("err = %v, want ErrKo (recapturing the ko point must be rejected)", err)
+	}
+
+	// Playing anywhere else is unaffected by the ko ban.
+	if err := LegalMove(board, size, Move{Point: 0, Color: White}, koPoint); err != nil {
+		t.Fatalf("unrelated point rejected: %v", err)
+	}
+}
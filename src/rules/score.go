@@ -0,0 +1,94 @@
+package rules
+
+// Score is the result of territory counting at the end of a game.
+type Score struct {
+	WhiteTerritory int
+	BlackTerritory int
+	WhiteScore     float64
+	BlackScore     float64
+	Winner         Cell
+}
+
+// Territory returns, for every point on the board, which color's
+// territory it belongs to. Stones keep their own color. An empty region
+// belongs to White or Black only if every stone bordering it is that
+// color; otherwise it is dame (Empty) and doesn't count for either side.
+func Territory(board []Cell, size int) []Cell {
+	owner := append([]Cell(nil), board...)
+	visited := make(map[int]bool)
+
+	for i, cell := range board {
+		if cell != Empty || visited[i] {
+			continue
+		}
+
+		region := []int{i}
+		visited[i] = true
+		borders := make(map[Cell]bool)
+
+		for cursor := 0; cursor < len(region); cursor++ {
+			p := region[cursor]
+			for _, n := range neighbors(p, size) {
+				switch board[n] {
+				case Empty:
+					if !visited[n] {
+						visited[n] = true
+						region = append(region, n)
+					}
+				default:
+					borders[board[n]] = true
+				}
+			}
+		}
+
+		owned := Empty
+		if len(borders) == 1 {
+			for color := range borders {
+				owned = color
+			}
+		}
+
+		for _, p := range region {
+			owner[p] = owned
+		}
+	}
+
+	return owner
+}
+
+// FinalScore tallies territory plus captures plus komi for each side and
+// reports the winner. Komi is added to White's score.
+func FinalScore(board []Cell, size int, whiteCaptures, blackCaptures int, komi float64) Score {
+	owner := Territory(board, size)
+
+	var whiteTerritory, blackTerritory int
+	for i, cell := range owner {
+		if board[i] != Empty {
+			continue
+		}
+		switch cell {
+		case White:
+			whiteTerritory++
+		case Black:
+			blackTerritory++
+		}
+	}
+
+	score := Score{
+		WhiteTerritory: whiteTerritory,
+		BlackTerritory: blackTerritory,
+		WhiteScore:     float64(whiteTerritory+whiteCaptures) + komi,
+		BlackScore:     float64(blackTerritory + blackCaptures),
+	}
+
+	switch {
+	case score.WhiteScore > score.BlackScore:
+		score.Winner = White
+	case score.BlackScore > score.WhiteScore:
+		score.Winner = Black
+	default:
+		score.Winner = Empty
+	}
+
+	return score
+}
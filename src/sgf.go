@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/JoeyShapiro/GoGo/src/rules"
+)
+
+// sgfCoord renders a 0-indexed row/col pair as the two-letter SGF point
+// label ("a"-"s" cover boards up to 19x19).
+func sgfCoord(row, col int) string {
+	return string(rune('a'+col)) + string(rune('a'+row))
+}
+
+// SGF renders the game's move history as a standard FF[4] SGF game tree.
+func (g *Game) SGF() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "(;GM[1]FF[4]SZ[%d]PB[Black]PW[White]", g.Size)
+
+	for _, move := range g.Moves {
+		color := "B"
+		if move.Player == White {
+			color = "W"
+		}
+		if move.Pass {
+			fmt.Fprintf(&b, ";%s[]C[%d]", color, move.Ctime)
+			continue
+		}
+		fmt.Fprintf(&b, ";%s[%s]C[%d]", color, sgfCoord(move.NRow, move.NCol), move.Ctime)
+	}
+
+	b.WriteString(")")
+	return b.String()
+}
+
+var sgfTagPattern = regexp.MustCompile(`([A-Z]+)\[([^\]]*)\]`)
+
+// ParseSGF reconstructs a Game from an FF[4] SGF game tree by replaying
+// every move it finds through the rules engine. Only SZ, B, W, C and RE
+// are read; every other property (including the GM, FF, PB and PW that
+// SGF writes) is ignored.
+func ParseSGF(r io.Reader) (*Game, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := sgfTagPattern.FindAllStringSubmatch(string(raw), -1)
+	if tags == nil {
+		return nil, errors.New("sgf: no properties found")
+	}
+
+	size := BOARD_SIZE
+	game := &Game{
+		Cursor:  -1,
+		Last:    -1,
+		Player:  White,
+		KoPoint: -1,
+	}
+
+	turn := 0
+	for _, tag := range tags {
+		key, value := tag[1], tag[2]
+
+		switch key {
+		case "SZ":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("sgf: bad SZ value %q: %w", value, err)
+			}
+			size = n
+		case "B", "W":
+			if game.Board == nil {
+				game.Board = make([]rules.Cell, size*size)
+			}
+
+			color := Black
+			if key == "W" {
+				color = White
+			}
+
+			if value == "" {
+				game.Moves = append(game.Moves, Move{
+					Turn:   turn,
+					Player: color,
+					NRow:   -1,
+					NCol:   -1,
+					Pass:   true,
+				})
+				turn++
+				continue
+			}
+
+			if len(value) != 2 {
+				return nil, fmt.Errorf("sgf: bad point %q", value)
+			}
+			col := int(value[0] - 'a')
+			row := int(value[1] - 'a')
+			point := row*size + col
+
+			move := rules.Move{Point: point, Color: color}
+			if err := rules.LegalMove(game.Board, size, move, game.KoPoint); err != nil {
+				return nil, fmt.Errorf("sgf: illegal move %s[%s]: %w", key, value, err)
+			}
+
+			newBoard, captured, newKo := rules.ApplyMove(game.Board, size, move)
+			game.Board = newBoard
+			game.KoPoint = newKo
+
+			switch color {
+			case White:
+				game.WhiteCaptures += len(captured)
+			case Black:
+				game.BlackCaptures += len(captured)
+			}
+
+			game.Moves = append(game.Moves, Move{
+				Turn:     turn,
+				Player:   color,
+				NRow:     row,
+				NCol:     col,
+				Captured: captured,
+			})
+			game.Last = point
+			turn++
+		case "C":
+			if n := len(game.Moves); n > 0 {
+				if ctime, err := strconv.ParseUint(value, 10, 64); err == nil {
+					game.Moves[n-1].Ctime = ctime
+				}
+			}
+		case "RE":
+			// RE is "B+..." or "W+..." for a decisive result, "0" or
+			// "Draw" for a tie, or unset/unknown; anything that isn't a
+			// clear B/W win leaves Winner at its Empty zero value.
+			switch {
+			case strings.HasPrefix(value, "B"):
+				game.Winner = Black
+			case strings.HasPrefix(value, "W"):
+				game.Winner = White
+			}
+		}
+	}
+
+	if game.Board == nil {
+		game.Board = make([]rules.Cell, size*size)
+	}
+	game.Size = size
+
+	return game, nil
+}